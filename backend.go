@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backend represents a single upstream server that requests can be
+// proxied to. The transport used to reach it (HTTP, FastCGI, ...) is
+// abstracted behind Upstream; everything else about pooling,
+// selection, and health checking is transport-agnostic.
+type Backend struct {
+	URL         *url.URL
+	Alive       bool
+	Weight      int
+	MaxInFlight int
+	ActiveConns atomic.Int64
+	mux         sync.RWMutex
+	Upstream    Upstream
+
+	// breakerOpen/breakerOpenedAt/failures are maintained by the
+	// owning Route's passive health checker; see health.go.
+	breakerOpen     atomic.Bool
+	breakerOpenedAt atomic.Int64
+	failures        *failureWindow
+}
+
+// ServeHTTP proxies the request to this backend while tracking the
+// number of in-flight connections it is currently serving, so that
+// selection policies such as least-connections can make informed
+// decisions.
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.ActiveConns.Add(1)
+	defer b.ActiveConns.Add(-1)
+	b.Upstream.ServeHTTP(w, r)
+}
+
+func (b *Backend) setAlive(alive bool) {
+	b.mux.Lock()
+	b.Alive = alive
+	b.mux.Unlock()
+}
+
+func (b *Backend) isAlive() (alive bool) {
+	b.mux.RLock()
+	alive = b.Alive
+	b.mux.RUnlock()
+	return alive
+}
+
+// saturated reports whether the backend is at its configured
+// concurrency cap, so selection policies can treat it as soft-down
+// instead of queueing requests behind a slow upstream.
+func (b *Backend) saturated() bool {
+	return b.MaxInFlight > 0 && b.ActiveConns.Load() >= int64(b.MaxInFlight)
+}
+
+// available reports whether a backend can currently take a request:
+// it must be alive, outside an open circuit, and under its
+// concurrency cap.
+func (b *Backend) available() bool {
+	return b.isAlive() && !b.saturated()
+}
+
+// openCircuit marks the backend down and starts its cooldown; the
+// owning route's health-check goroutine will probe it once the
+// cooldown elapses and reinstate it if the probe succeeds.
+func (b *Backend) openCircuit() {
+	b.breakerOpen.Store(true)
+	b.breakerOpenedAt.Store(time.Now().UnixNano())
+	b.setAlive(false)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheEntry is one cached response, including enough of the original
+// headers to replay it faithfully.
+type cacheEntry struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time // cacheable until this time
+	stale   time.Time // servable-but-stale until this time (expires, if SWR disabled)
+}
+
+// lruCache is a fixed-size, in-process cache keyed by string, evicting
+// the least recently used entry once it's full. It holds cacheEntry
+// values but doesn't know anything about HTTP.
+type lruCache struct {
+	mux        sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *cacheEntry
+}
+
+func newLRUCache(maxEntries int) *lruCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &lruCache{maxEntries: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) Get(key string) (*cacheEntry, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+func (c *lruCache) Set(key string, entry *cacheEntry) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	el := c.ll.PushFront(&lruItem{key: key, entry: entry})
+	c.items[key] = el
+	if c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*lruItem).key)
+		}
+	}
+}
+
+// RouteCache is the response cache for a single route: a size-capped
+// LRU plus the route's caching rules (TTL, stale window, vary
+// headers, bypass prefixes).
+type RouteCache struct {
+	store          *lruCache
+	defaultTTL     time.Duration
+	staleWindow    time.Duration
+	varyHeaders    []string
+	bypassPrefixes []string
+
+	revalidating sync.Map // key -> struct{}, dedupes concurrent SWR refreshes
+}
+
+// NewRouteCache builds a RouteCache from YAML config, applying sane
+// defaults for anything the operator left unset.
+func NewRouteCache(cfg CacheConfig) *RouteCache {
+	ttl := time.Duration(cfg.DefaultTTLMS) * time.Millisecond
+	if ttl <= 0 {
+		ttl = 5 * time.Second
+	}
+
+	return &RouteCache{
+		store:          newLRUCache(cfg.MaxEntries),
+		defaultTTL:     ttl,
+		staleWindow:    time.Duration(cfg.StaleWhileRevalidateMS) * time.Millisecond,
+		varyHeaders:    cfg.VaryHeaders,
+		bypassPrefixes: cfg.BypassPrefixes,
+	}
+}
+
+// key builds a cache key from the method, host, path, query, and any
+// configured vary headers.
+func (rc *RouteCache) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte('|')
+	b.WriteString(r.Host)
+	b.WriteByte('|')
+	b.WriteString(r.URL.Path)
+	b.WriteByte('|')
+	b.WriteString(r.URL.RawQuery)
+	for _, h := range rc.varyHeaders {
+		b.WriteByte('|')
+		b.WriteString(h)
+		b.WriteByte('=')
+		b.WriteString(r.Header.Get(h))
+	}
+	return b.String()
+}
+
+// bypassed reports whether a request should skip the cache entirely:
+// anything but GET/HEAD, or a path under one of the route's configured
+// bypass prefixes.
+func (rc *RouteCache) bypassed(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return true
+	}
+	for _, p := range rc.bypassPrefixes {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// maybeStore stores rec's captured response under key, unless the
+// upstream's Cache-Control forbids it (no-store, private, no-cache) or
+// the resolved TTL is non-positive. This cache has no conditional-GET
+// revalidation path, so the only honest way to honor no-cache's "store
+// but revalidate before every use" is to not serve it from the cache
+// at all.
+func (rc *RouteCache) maybeStore(key string, rec *responseRecorder) {
+	if rec.buf == nil {
+		return
+	}
+
+	cc := parseCacheControl(rec.header)
+	if cc.noStore || cc.private || cc.noCache {
+		return
+	}
+
+	ttl := rc.defaultTTL
+	if cc.hasMaxAge {
+		ttl = cc.maxAge
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	rc.store.Set(key, &cacheEntry{
+		status:  rec.status,
+		header:  rec.header,
+		body:    append([]byte(nil), rec.buf.Bytes()...),
+		expires: now.Add(ttl),
+		stale:   now.Add(ttl + rc.staleWindow),
+	})
+}
+
+// revalidateOnce refreshes key in the background by replaying req
+// against the route's backends, deduping concurrent refreshes for the
+// same key so a burst of stale hits only triggers one upstream call.
+func (rc *RouteCache) revalidateOnce(route *Route, r *http.Request, key string) {
+	if _, inFlight := rc.revalidating.LoadOrStore(key, struct{}{}); inFlight {
+		return
+	}
+
+	req := r.Clone(context.Background())
+	go func() {
+		defer rc.revalidating.Delete(key)
+		rec := newResponseRecorder(newDiscardResponseWriter(), true)
+		serveProxied(rec, req, route, time.Now())
+		rc.maybeStore(key, rec)
+	}()
+}
+
+// cacheControl is the subset of Cache-Control directives the cache
+// honors from a backend's response.
+type cacheControl struct {
+	noStore   bool
+	private   bool
+	noCache   bool
+	maxAge    time.Duration
+	hasMaxAge bool
+}
+
+func parseCacheControl(h http.Header) cacheControl {
+	var cc cacheControl
+	for _, part := range strings.Split(h.Get("Cache-Control"), ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		switch {
+		case part == "no-store":
+			cc.noStore = true
+		case part == "private":
+			cc.private = true
+		case part == "no-cache":
+			cc.noCache = true
+		case strings.HasPrefix(part, "max-age="):
+			if secs, err := strconv.Atoi(strings.TrimPrefix(part, "max-age=")); err == nil {
+				cc.maxAge = time.Duration(secs) * time.Second
+				cc.hasMaxAge = true
+			}
+		}
+	}
+	return cc
+}
+
+// writeCachedEntry replays a cached response verbatim to the client.
+func writeCachedEntry(w http.ResponseWriter, entry *cacheEntry) {
+	for key, values := range entry.header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// discardResponseWriter satisfies http.ResponseWriter for requests
+// that have no real client waiting on them, such as a background
+// stale-while-revalidate refresh. It still has to hold onto a real
+// header map: the upstream writes response headers (including
+// Cache-Control) through Header() before calling WriteHeader, and the
+// response cache needs those headers to decide whether to store the
+// refreshed entry.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func newDiscardResponseWriter() *discardResponseWriter {
+	return &discardResponseWriter{header: http.Header{}}
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+
+// serveCached serves r through route's cache, falling back to
+// serveProxied on a miss and populating the cache from the result. A
+// fresh hit is served directly; a stale-but-within-window hit is
+// served immediately and triggers a background revalidation.
+func serveCached(w http.ResponseWriter, r *http.Request, route *Route, start time.Time) {
+	key := route.Cache.key(r)
+
+	if entry, ok := route.Cache.store.Get(key); ok {
+		now := time.Now()
+		if now.Before(entry.expires) {
+			cacheResultTotal.WithLabelValues(route.Label(), "hit").Inc()
+			writeCachedEntry(w, entry)
+			logRequest(r, route, nil, start, entry.status)
+			return
+		}
+		if route.Cache.staleWindow > 0 && now.Before(entry.stale) {
+			cacheResultTotal.WithLabelValues(route.Label(), "stale").Inc()
+			writeCachedEntry(w, entry)
+			logRequest(r, route, nil, start, entry.status)
+			route.Cache.revalidateOnce(route, r, key)
+			return
+		}
+	}
+
+	cacheResultTotal.WithLabelValues(route.Label(), "miss").Inc()
+	rec := newResponseRecorder(w, true)
+	serveProxied(rec, r, route, start)
+	route.Cache.maybeStore(key, rec)
+}
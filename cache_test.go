@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   cacheControl
+	}{
+		{
+			name:   "max-age",
+			header: "max-age=30",
+			want:   cacheControl{maxAge: 30 * time.Second, hasMaxAge: true},
+		},
+		{
+			name:   "no-store",
+			header: "no-store",
+			want:   cacheControl{noStore: true},
+		},
+		{
+			name:   "private",
+			header: "private",
+			want:   cacheControl{private: true},
+		},
+		{
+			name:   "no-cache",
+			header: "no-cache",
+			want:   cacheControl{noCache: true},
+		},
+		{
+			name:   "combined directives",
+			header: "private, max-age=10, no-cache",
+			want:   cacheControl{private: true, noCache: true, maxAge: 10 * time.Second, hasMaxAge: true},
+		},
+		{
+			name:   "directives are case-insensitive",
+			header: "No-Store, Private, NO-CACHE, Max-Age=15",
+			want:   cacheControl{noStore: true, private: true, noCache: true, maxAge: 15 * time.Second, hasMaxAge: true},
+		},
+		{
+			name:   "empty",
+			header: "",
+			want:   cacheControl{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.header != "" {
+				h.Set("Cache-Control", tt.header)
+			}
+			got := parseCacheControl(h)
+			if got != tt.want {
+				t.Errorf("parseCacheControl(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func recorderWithCacheControl(t *testing.T, cacheControlHeader, body string) *responseRecorder {
+	t.Helper()
+	rec := newResponseRecorder(newDiscardResponseWriter(), true)
+	if cacheControlHeader != "" {
+		rec.Header().Set("Cache-Control", cacheControlHeader)
+	}
+	if _, err := rec.Write([]byte(body)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return rec
+}
+
+func TestRouteCacheMaybeStoreRespectsDirectives(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl string
+		wantCached   bool
+	}{
+		{name: "cacheable by default", cacheControl: "", wantCached: true},
+		{name: "max-age still caches", cacheControl: "max-age=60", wantCached: true},
+		{name: "no-store is not cached", cacheControl: "no-store", wantCached: false},
+		{name: "private is not cached", cacheControl: "private", wantCached: false},
+		{name: "no-cache is not cached", cacheControl: "no-cache", wantCached: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := NewRouteCache(CacheConfig{Enabled: true, DefaultTTLMS: 1000})
+			rec := recorderWithCacheControl(t, tt.cacheControl, "body")
+			rc.maybeStore("key", rec)
+
+			_, ok := rc.store.Get("key")
+			if ok != tt.wantCached {
+				t.Errorf("cached = %v, want %v", ok, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestRouteCacheTTLAndStaleTransitions(t *testing.T) {
+	rc := NewRouteCache(CacheConfig{
+		Enabled:                true,
+		DefaultTTLMS:           20,
+		StaleWhileRevalidateMS: 200,
+	})
+	rec := recorderWithCacheControl(t, "", "body")
+	rc.maybeStore("key", rec)
+
+	entry, ok := rc.store.Get("key")
+	if !ok {
+		t.Fatal("expected entry to be cached")
+	}
+	if !time.Now().Before(entry.expires) {
+		t.Fatal("entry should still be fresh immediately after storing")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	entry, ok = rc.store.Get("key")
+	if !ok {
+		t.Fatal("entry should still be retrievable (stale) after its TTL elapses")
+	}
+	if time.Now().Before(entry.expires) {
+		t.Fatal("entry should no longer be fresh after its TTL elapses")
+	}
+	if !time.Now().Before(entry.stale) {
+		t.Fatal("entry should still be within its stale-while-revalidate window")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	entry, ok = rc.store.Get("key")
+	if !ok {
+		t.Fatal("entry is still in the LRU store (eviction is size-based, not time-based)")
+	}
+	if time.Now().Before(entry.stale) {
+		t.Fatal("entry should be past its stale window by now")
+	}
+}
+
+func TestLRUCacheEvictsOldest(t *testing.T) {
+	c := newLRUCache(2)
+	c.Set("a", &cacheEntry{})
+	c.Set("b", &cacheEntry{})
+	c.Set("c", &cacheEntry{}) // should evict "a"
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected \"a\" to have been evicted")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("expected \"b\" to still be cached")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("expected \"c\" to still be cached")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the top-level YAML configuration for the load balancer.
+type Config struct {
+	ListenAddr string        `yaml:"listen_addr"`
+	Routes     []RouteConfig `yaml:"routes"`
+}
+
+// RouteConfig describes one independent backend pool and the
+// host/path prefix that should be routed to it.
+type RouteConfig struct {
+	Host             string            `yaml:"host"`
+	Prefix           string            `yaml:"prefix"`
+	Policy           string            `yaml:"policy"`
+	HashKey          string            `yaml:"hash_key"`
+	RetryCount       *int              `yaml:"retry_count"`
+	RetryDelayMS     int               `yaml:"retry_delay_ms"`
+	BackendTimeoutMS int               `yaml:"backend_timeout_ms"`
+	HealthCheck      HealthCheckConfig `yaml:"health_check"`
+	RateLimit        *RateLimitConfig  `yaml:"rate_limit"`
+	ClientRateLimit  *RateLimitConfig  `yaml:"per_client_rate_limit"`
+	ScriptRoot       string            `yaml:"script_root"`
+	Cache            *CacheConfig      `yaml:"cache"`
+	Backends         []BackendConfig   `yaml:"backends"`
+}
+
+// CacheConfig configures the optional response cache sitting in front
+// of a route's backends.
+type CacheConfig struct {
+	Enabled                bool     `yaml:"enabled"`
+	DefaultTTLMS           int      `yaml:"default_ttl_ms"`
+	StaleWhileRevalidateMS int      `yaml:"stale_while_revalidate_ms"`
+	MaxEntries             int      `yaml:"max_entries"`
+	VaryHeaders            []string `yaml:"vary_headers"`
+	BypassPrefixes         []string `yaml:"bypass_prefixes"`
+}
+
+// RateLimitConfig configures a token-bucket limiter: rps tokens are
+// added per second, up to burst tokens held at once.
+type RateLimitConfig struct {
+	RPS   float64 `yaml:"rps"`
+	Burst int     `yaml:"burst"`
+}
+
+// HealthCheckConfig selects which health-checking modes are enabled
+// for a route. Either, both, or neither may be set; with neither set,
+// the route falls back to a plain TCP dial on a 20s interval.
+type HealthCheckConfig struct {
+	Active  *ActiveHealthCheckYAML  `yaml:"active"`
+	Passive *PassiveHealthCheckYAML `yaml:"passive"`
+}
+
+// ActiveHealthCheckYAML configures HTTP probing of a route's backends.
+type ActiveHealthCheckYAML struct {
+	Path           string `yaml:"path"`
+	ExpectedStatus []int  `yaml:"expected_status"`
+	IntervalMS     int    `yaml:"interval_ms"`
+	TimeoutMS      int    `yaml:"timeout_ms"`
+}
+
+// PassiveHealthCheckYAML configures circuit-breaking driven by
+// observed request failures.
+type PassiveHealthCheckYAML struct {
+	WindowMS         int `yaml:"window_ms"`
+	FailureThreshold int `yaml:"failure_threshold"`
+	CooldownMS       int `yaml:"cooldown_ms"`
+}
+
+// BackendConfig describes a single upstream within a route's pool.
+type BackendConfig struct {
+	URL         string `yaml:"url"`
+	Weight      int    `yaml:"weight"`
+	MaxInFlight int    `yaml:"max_in_flight"`
+}
+
+// Retries returns the configured retry count, defaulting to 3 when
+// the operator left retry_count unset. An explicit retry_count: 0
+// disables retries rather than falling back to the default.
+func (rc RouteConfig) Retries() int {
+	if rc.RetryCount == nil {
+		return 3
+	}
+	return *rc.RetryCount
+}
+
+func (rc RouteConfig) RetryDelay() time.Duration {
+	if rc.RetryDelayMS <= 0 {
+		return 10 * time.Millisecond
+	}
+	return time.Duration(rc.RetryDelayMS) * time.Millisecond
+}
+
+func (rc RouteConfig) BackendTimeout() time.Duration {
+	return time.Duration(rc.BackendTimeoutMS) * time.Millisecond
+}
+
+// LoadConfig reads and validates a YAML config file, filling in
+// defaults for any field the operator left unset.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+
+	if cfg.ListenAddr == "" {
+		cfg.ListenAddr = ":3000"
+	}
+	if len(cfg.Routes) == 0 {
+		return nil, fmt.Errorf("config must define at least one route")
+	}
+	for i := range cfg.Routes {
+		if len(cfg.Routes[i].Backends) == 0 {
+			return nil, fmt.Errorf("route %d (%s%s) has no backends", i, cfg.Routes[i].Host, cfg.Routes[i].Prefix)
+		}
+	}
+
+	return &cfg, nil
+}
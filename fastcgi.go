@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// Minimal FastCGI client implementing just enough of the protocol
+// (responder role) to proxy an HTTP request to a FastCGI worker such
+// as PHP-FPM, plus the FCGI_GET_VALUES probe used for health checks.
+// See https://fastcgi-archives.github.io/FastCGI_Specification.html.
+
+const (
+	fcgiVersion1 = 1
+
+	fcgiBeginRequest    = 1
+	fcgiAbortRequest    = 2
+	fcgiEndRequest      = 3
+	fcgiParams          = 4
+	fcgiStdin           = 5
+	fcgiStdout          = 6
+	fcgiStderr          = 7
+	fcgiGetValues       = 9
+	fcgiGetValuesResult = 10
+
+	fcgiRoleResponder = 1
+
+	fcgiRequestID = 1
+)
+
+type fcgiHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+func writeRecord(w io.Writer, recType uint8, reqID uint16, content []byte) error {
+	padding := (8 - len(content)%8) % 8
+	h := fcgiHeader{
+		Version:       fcgiVersion1,
+		Type:          recType,
+		RequestID:     reqID,
+		ContentLength: uint16(len(content)),
+		PaddingLength: uint8(padding),
+	}
+	if err := binary.Write(w, binary.BigEndian, h); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if padding > 0 {
+		if _, err := w.Write(make([]byte, padding)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecord(r io.Reader) (fcgiHeader, []byte, error) {
+	var h fcgiHeader
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return h, nil, err
+	}
+	content := make([]byte, h.ContentLength)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return h, nil, err
+	}
+	if h.PaddingLength > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(h.PaddingLength)); err != nil {
+			return h, nil, err
+		}
+	}
+	return h, content, nil
+}
+
+// encodeParams packs a set of FastCGI name-value pairs (e.g. CGI
+// params) into the wire format used by FCGI_PARAMS records.
+func encodeParams(params map[string]string) []byte {
+	var buf bytes.Buffer
+	for name, value := range params {
+		writeParamLen(&buf, len(name))
+		writeParamLen(&buf, len(value))
+		buf.WriteString(name)
+		buf.WriteString(value)
+	}
+	return buf.Bytes()
+}
+
+func writeParamLen(buf *bytes.Buffer, n int) {
+	if n < 128 {
+		buf.WriteByte(byte(n))
+		return
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n)|0x80000000)
+	buf.Write(b[:])
+}
+
+// beginRequestBody encodes the FCGI_BEGIN_REQUEST body for a
+// responder-role request.
+func beginRequestBody(keepConn bool) []byte {
+	body := make([]byte, 8)
+	binary.BigEndian.PutUint16(body[0:2], fcgiRoleResponder)
+	if keepConn {
+		body[2] = 1
+	}
+	return body
+}
+
+// fcgiClient drives one FastCGI request/response exchange over conn.
+type fcgiClient struct {
+	conn net.Conn
+}
+
+// Do sends params and stdin as a single responder request and returns
+// the parsed HTTP status, headers, and body from the worker's stdout.
+func (c *fcgiClient) Do(params map[string]string, stdin io.Reader) (int, http.Header, []byte, error) {
+	if err := writeRecord(c.conn, fcgiBeginRequest, fcgiRequestID, beginRequestBody(false)); err != nil {
+		return 0, nil, nil, err
+	}
+
+	encoded := encodeParams(params)
+	if len(encoded) > 0 {
+		if err := writeRecord(c.conn, fcgiParams, fcgiRequestID, encoded); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	if err := writeRecord(c.conn, fcgiParams, fcgiRequestID, nil); err != nil {
+		return 0, nil, nil, err
+	}
+
+	if stdin != nil {
+		buf := make([]byte, 8192)
+		for {
+			n, rerr := stdin.Read(buf)
+			if n > 0 {
+				if err := writeRecord(c.conn, fcgiStdin, fcgiRequestID, buf[:n]); err != nil {
+					return 0, nil, nil, err
+				}
+			}
+			if rerr == io.EOF {
+				break
+			}
+			if rerr != nil {
+				return 0, nil, nil, rerr
+			}
+		}
+	}
+	if err := writeRecord(c.conn, fcgiStdin, fcgiRequestID, nil); err != nil {
+		return 0, nil, nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	for {
+		h, content, err := readRecord(c.conn)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("read fcgi record: %w", err)
+		}
+		switch h.Type {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			return parseCGIResponse(stdout.Bytes())
+		}
+	}
+}
+
+// parseCGIResponse splits a CGI-style response (headers, blank line,
+// body) the way PHP-FPM and similar FastCGI workers emit it.
+func parseCGIResponse(raw []byte) (int, http.Header, []byte, error) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw)))
+	mimeHeader, err := reader.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return 0, nil, nil, fmt.Errorf("parse fcgi response headers: %w", err)
+	}
+
+	status := http.StatusOK
+	header := http.Header(mimeHeader)
+	if s := header.Get("Status"); s != "" {
+		if len(s) >= 3 {
+			if code, convErr := strconv.Atoi(s[:3]); convErr == nil {
+				status = code
+			}
+		}
+		header.Del("Status")
+	}
+
+	body, err := io.ReadAll(reader.R)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("read fcgi response body: %w", err)
+	}
+	return status, header, body, nil
+}
+
+// probeGetValues issues a bare FCGI_GET_VALUES request and reports
+// whether the worker answered with FCGI_GET_VALUES_RESULT, which is
+// enough to confirm it is alive and speaking the protocol.
+func probeGetValues(network, addr string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	query := encodeParams(map[string]string{"FCGI_MAX_CONNS": ""})
+	if err := writeRecord(conn, fcgiGetValues, 0, query); err != nil {
+		return false
+	}
+
+	h, _, err := readRecord(conn)
+	if err != nil {
+		return false
+	}
+	return h.Type == fcgiGetValuesResult
+}
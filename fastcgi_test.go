@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestWriteReadRecordRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	content := []byte("hello world")
+	if err := writeRecord(&buf, fcgiStdout, fcgiRequestID, content); err != nil {
+		t.Fatalf("writeRecord: %v", err)
+	}
+
+	// Content isn't a multiple of 8 bytes, so the record should be
+	// padded out to an 8-byte boundary.
+	wantPadding := (8 - len(content)%8) % 8
+	wantLen := 8 + len(content) + wantPadding
+	if buf.Len() != wantLen {
+		t.Fatalf("wrote %d bytes, want %d (content %d + padding %d + header 8)", buf.Len(), wantLen, len(content), wantPadding)
+	}
+
+	h, got, err := readRecord(&buf)
+	if err != nil {
+		t.Fatalf("readRecord: %v", err)
+	}
+	if h.Type != fcgiStdout || h.RequestID != fcgiRequestID {
+		t.Fatalf("got header %+v, want type=%d id=%d", h, fcgiStdout, fcgiRequestID)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("got content %q, want %q", got, content)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("%d trailing bytes left unread, padding not consumed", buf.Len())
+	}
+}
+
+func TestEncodeParamsRoundTrip(t *testing.T) {
+	params := map[string]string{
+		"REQUEST_METHOD": "GET",
+		"SCRIPT_NAME":    "/index.php",
+		// A value long enough to force the 4-byte length prefix.
+		"HTTP_X_BIG": string(make([]byte, 200)),
+	}
+	encoded := encodeParams(params)
+
+	got := map[string]string{}
+	for len(encoded) > 0 {
+		nameLen, n1 := readParamLen(encoded)
+		encoded = encoded[n1:]
+		valueLen, n2 := readParamLen(encoded)
+		encoded = encoded[n2:]
+		name := string(encoded[:nameLen])
+		encoded = encoded[nameLen:]
+		value := string(encoded[:valueLen])
+		encoded = encoded[valueLen:]
+		got[name] = value
+	}
+
+	if len(got) != len(params) {
+		t.Fatalf("decoded %d params, want %d", len(got), len(params))
+	}
+	for name, want := range params {
+		if got[name] != want {
+			t.Errorf("param %q = %q, want %q", name, got[name], want)
+		}
+	}
+}
+
+// readParamLen is the inverse of writeParamLen, used only by the test
+// above to decode what encodeParams wrote.
+func readParamLen(b []byte) (int, int) {
+	if b[0]&0x80 == 0 {
+		return int(b[0]), 1
+	}
+	n := int(b[0]&0x7f)<<24 | int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+	return n, 4
+}
+
+func TestParseCGIResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		wantStatus int
+		wantHeader string
+		wantBody   string
+	}{
+		{
+			name:       "default status",
+			raw:        "Content-Type: text/plain\r\n\r\nhello",
+			wantStatus: http.StatusOK,
+			wantHeader: "text/plain",
+			wantBody:   "hello",
+		},
+		{
+			name:       "explicit status",
+			raw:        "Status: 404 Not Found\r\nContent-Type: text/plain\r\n\r\nmissing",
+			wantStatus: http.StatusNotFound,
+			wantHeader: "text/plain",
+			wantBody:   "missing",
+		},
+		{
+			name:       "malformed status shorter than 3 chars does not panic",
+			raw:        "Status: 4\r\nContent-Type: text/plain\r\n\r\nbody",
+			wantStatus: http.StatusOK,
+			wantHeader: "text/plain",
+			wantBody:   "body",
+		},
+		{
+			name:       "empty status value",
+			raw:        "Status: \r\nContent-Type: text/plain\r\n\r\nbody",
+			wantStatus: http.StatusOK,
+			wantHeader: "text/plain",
+			wantBody:   "body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header, body, err := parseCGIResponse([]byte(tt.raw))
+			if err != nil {
+				t.Fatalf("parseCGIResponse: %v", err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if got := header.Get("Content-Type"); got != tt.wantHeader {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantHeader)
+			}
+			if header.Get("Status") != "" {
+				t.Errorf("Status header should be stripped, got %q", header.Get("Status"))
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
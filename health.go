@@ -0,0 +1,227 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ActiveHealthChecker probes a backend with real HTTP requests against
+// a configured path and treats one of a set of expected status codes
+// as healthy.
+type ActiveHealthChecker struct {
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+
+	expected map[int]bool
+	client   *http.Client
+}
+
+// NewActiveHealthChecker builds a checker from YAML config, applying
+// sane defaults for anything the operator left unset.
+func NewActiveHealthChecker(cfg ActiveHealthCheckYAML) *ActiveHealthChecker {
+	path := cfg.Path
+	if path == "" {
+		path = "/healthz"
+	}
+
+	interval := time.Duration(cfg.IntervalMS) * time.Millisecond
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	timeout := time.Duration(cfg.TimeoutMS) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	expected := make(map[int]bool, len(cfg.ExpectedStatus))
+	for _, code := range cfg.ExpectedStatus {
+		expected[code] = true
+	}
+	if len(expected) == 0 {
+		expected[http.StatusOK] = true
+	}
+
+	return &ActiveHealthChecker{
+		Path:     path,
+		Interval: interval,
+		Timeout:  timeout,
+		expected: expected,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Check probes a backend and reports whether it's healthy. FastCGI
+// backends are probed with FCGI_GET_VALUES; everything else gets a
+// real GET against the configured health path.
+func (c *ActiveHealthChecker) Check(b *Backend) bool {
+	if fu, ok := b.Upstream.(*fcgiUpstream); ok {
+		return probeGetValues(fu.network, fu.addr, c.Timeout)
+	}
+
+	u := *b.URL
+	u.Path = c.Path
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return c.expected[resp.StatusCode]
+}
+
+// PassiveBreakerConfig configures circuit-breaking driven by observed
+// request failures rather than active probing.
+type PassiveBreakerConfig struct {
+	Window           time.Duration
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// NewPassiveBreakerConfig builds a PassiveBreakerConfig from YAML
+// config, applying sane defaults for anything left unset.
+func NewPassiveBreakerConfig(cfg PassiveHealthCheckYAML) *PassiveBreakerConfig {
+	window := time.Duration(cfg.WindowMS) * time.Millisecond
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+
+	cooldown := time.Duration(cfg.CooldownMS) * time.Millisecond
+	if cooldown <= 0 {
+		cooldown = 10 * time.Second
+	}
+
+	return &PassiveBreakerConfig{Window: window, FailureThreshold: threshold, Cooldown: cooldown}
+}
+
+// failureWindow tracks how many failures a backend has seen within a
+// trailing time window.
+type failureWindow struct {
+	mux    sync.Mutex
+	window time.Duration
+	times  []time.Time
+}
+
+func newFailureWindow(window time.Duration) *failureWindow {
+	return &failureWindow{window: window}
+}
+
+// Record adds a failure at now and returns the number of failures
+// still within the window, pruning anything older.
+func (f *failureWindow) Record(now time.Time) int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	cutoff := now.Add(-f.window)
+	kept := f.times[:0]
+	for _, t := range f.times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	f.times = append(kept, now)
+	return len(f.times)
+}
+
+func (f *failureWindow) Reset() {
+	f.mux.Lock()
+	f.times = nil
+	f.mux.Unlock()
+}
+
+// RecordFailure observes a backend failure for the route. If passive
+// circuit-breaking is configured, it feeds the backend's failure
+// window and trips the circuit once the threshold is crossed.
+// Otherwise it falls back to the original behavior of marking the
+// backend down outright.
+func (route *Route) RecordFailure(b *Backend) {
+	if route.Passive == nil {
+		route.Pool.MarkServiceDown(b.URL)
+		backendUp.WithLabelValues(route.Label(), b.URL.String()).Set(0)
+		return
+	}
+
+	if b.failures.Record(time.Now()) >= route.Passive.FailureThreshold {
+		b.openCircuit()
+		backendUp.WithLabelValues(route.Label(), b.URL.String()).Set(0)
+	}
+}
+
+// probe checks a single backend using the route's active HTTP checker
+// if one is configured, falling back to a plain TCP dial.
+func (route *Route) probe(b *Backend) bool {
+	if route.Active != nil {
+		return route.Active.Check(b)
+	}
+	return isBackendAlive(b.URL)
+}
+
+// checkBackend reconciles one backend's alive state. Backends with an
+// open circuit are left down until their cooldown elapses, at which
+// point a single probe decides whether to reinstate them.
+func (route *Route) checkBackend(b *Backend) {
+	if b.breakerOpen.Load() {
+		if route.Passive == nil || time.Since(time.Unix(0, b.breakerOpenedAt.Load())) < route.Passive.Cooldown {
+			return
+		}
+		if route.probe(b) {
+			b.breakerOpen.Store(false)
+			b.failures.Reset()
+			route.setAlive(b, true)
+		} else {
+			b.breakerOpenedAt.Store(time.Now().UnixNano())
+		}
+		return
+	}
+
+	route.setAlive(b, route.probe(b))
+}
+
+// setAlive updates a backend's alive state and keeps the health-check
+// gauge in sync with it.
+func (route *Route) setAlive(b *Backend, alive bool) {
+	b.setAlive(alive)
+	gauge := float64(0)
+	if alive {
+		gauge = 1
+	}
+	backendUp.WithLabelValues(route.Label(), b.URL.String()).Set(gauge)
+}
+
+// runHealthChecks drives both active probing and passive circuit
+// reinstatement for the route until stop is closed.
+func (route *Route) runHealthChecks(stop <-chan struct{}) {
+	interval := 20 * time.Second
+	if route.Active != nil {
+		interval = route.Active.Interval
+	}
+	if route.Passive != nil && route.Passive.Cooldown < interval {
+		interval = route.Passive.Cooldown
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			for _, b := range route.Pool.backends {
+				route.checkBackend(b)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestRoute(t *testing.T, target *httptest.Server, passive *PassiveBreakerConfig) (*Route, *Backend) {
+	t.Helper()
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		t.Fatalf("parse backend url: %v", err)
+	}
+
+	b := &Backend{URL: u, Weight: 1}
+	b.setAlive(true)
+
+	route := &Route{
+		Pool:    NewServerPool(nil),
+		Passive: passive,
+	}
+	if passive != nil {
+		b.failures = newFailureWindow(passive.Window)
+	}
+	route.Pool.AddBackend(b)
+
+	return route, b
+}
+
+func TestRecordFailureTripsAndReinstatesCircuit(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	passive := &PassiveBreakerConfig{Window: time.Minute, FailureThreshold: 2, Cooldown: 20 * time.Millisecond}
+	route, b := newTestRoute(t, up, passive)
+
+	route.RecordFailure(b)
+	if !b.isAlive() {
+		t.Fatal("backend should still be alive after a single failure below the threshold")
+	}
+
+	route.RecordFailure(b)
+	if b.isAlive() {
+		t.Fatal("backend should be marked down once the failure threshold is crossed")
+	}
+	if !b.breakerOpen.Load() {
+		t.Fatal("expected the circuit to be open")
+	}
+
+	// Still within the cooldown: checkBackend should leave it down
+	// without probing.
+	route.checkBackend(b)
+	if b.isAlive() {
+		t.Fatal("backend should remain down before its cooldown elapses")
+	}
+
+	time.Sleep(passive.Cooldown + 10*time.Millisecond)
+
+	route.checkBackend(b)
+	if !b.isAlive() {
+		t.Fatal("backend should be reinstated once its cooldown elapses and the probe succeeds")
+	}
+	if b.breakerOpen.Load() {
+		t.Fatal("circuit should be closed after reinstatement")
+	}
+}
+
+func TestCheckBackendReopensCircuitOnFailedProbe(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer up.Close()
+
+	passive := &PassiveBreakerConfig{Window: time.Minute, FailureThreshold: 1, Cooldown: 10 * time.Millisecond}
+	route, b := newTestRoute(t, up, passive)
+	route.Active = NewActiveHealthChecker(ActiveHealthCheckYAML{ExpectedStatus: []int{http.StatusOK}})
+
+	route.RecordFailure(b)
+	if b.isAlive() {
+		t.Fatal("backend should be down after crossing the failure threshold")
+	}
+
+	time.Sleep(passive.Cooldown + 10*time.Millisecond)
+
+	route.checkBackend(b)
+	if b.isAlive() {
+		t.Fatal("backend should stay down when its reinstatement probe fails")
+	}
+	if !b.breakerOpen.Load() {
+		t.Fatal("circuit should remain open after a failed reinstatement probe")
+	}
+}
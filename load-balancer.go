@@ -1,31 +1,20 @@
 package main
 
 import (
-	"context"
-	"fmt"
-	"log"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
-	"sync"
+	"os"
+	"os/signal"
+	"strconv"
 	"sync/atomic"
+	"syscall"
 	"time"
-)
-
-type Backend struct {
-	URL          *url.URL
-	Alive        bool
-	mux          sync.RWMutex
-	ReverseProxy *httputil.ReverseProxy
-}
 
-type ServerPool struct {
-	backends []*Backend
-	current  uint64
-}
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
 
-var serverPool ServerPool
+var router atomic.Pointer[Router]
 
 type ctxKey int
 
@@ -35,73 +24,114 @@ const (
 )
 
 func main() {
-	targets := []string{
-		"http://localhost:8081",
-		"http://localhost:8082",
-		"http://localhost:8083",
+	configPath := "backends.yaml"
+	if len(os.Args) > 1 {
+		configPath = os.Args[1]
 	}
 
-	initializeServerPool(targets)
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		logger.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	rt, err := NewRouter(cfg)
+	if err != nil {
+		logger.Error("failed to build router", "error", err)
+		os.Exit(1)
+	}
+	router.Store(rt)
 
-	// register handler
 	http.HandleFunc("/", lb)
+	http.Handle("/metrics", promhttp.Handler())
 
-	// start server
-	port := 3000
 	server := &http.Server{
-		Addr: fmt.Sprintf(":%d", port),
+		Addr: cfg.ListenAddr,
 	}
 
-	go healthCheck()
+	go watchReload(configPath)
 
-	fmt.Printf("Load balancer started at %s\n", server.Addr)
+	logger.Info("load balancer started", "addr", server.Addr)
 	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("server failed: %v", err)
+		logger.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// watchReload rebuilds the router from configPath whenever the
+// process receives SIGHUP, so operators can change backends without
+// dropping in-flight connections. The previous router (and its
+// in-flight requests) keeps running until the new one is swapped in
+// atomically.
+func watchReload(configPath string) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		logger.Info("received SIGHUP, reloading config")
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			logger.Error("config reload failed, keeping previous config", "error", err)
+			continue
+		}
+		rt, err := NewRouter(cfg)
+		if err != nil {
+			logger.Error("config reload failed, keeping previous config", "error", err)
+			continue
+		}
+		old := router.Swap(rt)
+		if old != nil {
+			old.Stop()
+		}
+		logger.Info("config reloaded")
 	}
 }
 
 func lb(w http.ResponseWriter, r *http.Request) {
-	peer := serverPool.GetNextPeer()
-	if peer != nil {
-		peer.ReverseProxy.ServeHTTP(w, r)
+	start := time.Now()
+	route := router.Load().Match(r)
+	if route == nil {
+		http.Error(w, "no route configured for this request", http.StatusNotFound)
+		return
+	}
+
+	if allowed, retryAfter := route.Limiter.Allow(r); !allowed {
+		rateLimitedTotal.WithLabelValues(route.Label()).Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second).Seconds())+1))
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
-	http.Error(w, "Service not available", http.StatusServiceUnavailable)
-}
 
-func (s *ServerPool) AddBackend(backend *Backend) {
-	s.backends = append(s.backends, backend)
+	requestsTotal.WithLabelValues(route.Label()).Inc()
+
+	if route.Cache != nil && !route.Cache.bypassed(r) {
+		serveCached(w, r, route, start)
+		return
+	}
+
+	serveProxied(newResponseRecorder(w, false), r, route, start)
 }
 
-func initializeServerPool(targets []string) {
-	for _, t := range targets {
-		u, _ := url.Parse(t)
-		proxy := httputil.NewSingleHostReverseProxy(u)
-		backend := &Backend{
-			URL:          u,
-			ReverseProxy: proxy,
-		}
-		b := backend
-		p := proxy
-		proxy.ErrorHandler = func(writter http.ResponseWriter, req *http.Request, e error) {
-			log.Printf("[%s] %s\n", b.URL, e.Error())
-			retries := GetRetryFromContext(req)
-			attempts := GetAttemptsFromContext(req)
-			if retries < 3 {
-				time.Sleep(10 * time.Millisecond)
-				ctx := context.WithValue(req.Context(), Retry, retries+1)
-				p.ServeHTTP(writter, req.WithContext(ctx))
-				return
-			}
-
-			serverPool.MarkServiceDown(backend.URL)
-			// if the same rN(next
-			ctx := context.WithValue(req.Context(), Attempts, attempts+1)
-			lb(writter, req.WithContext(ctx))
-		}
-		backend.setAlive(true)
-		serverPool.AddBackend(backend)
+// serveProxied selects a peer for the request and proxies to it,
+// recording metrics and the access log. rec is the caller's
+// ResponseWriter wrapped in a responseRecorder; callers that want the
+// response body (e.g. the response cache) pass one built with
+// captureBody true.
+func serveProxied(rec *responseRecorder, r *http.Request, route *Route, start time.Time) {
+	peer := route.Pool.NextPeer(r)
+	if peer == nil {
+		http.Error(rec, "Service not available", http.StatusServiceUnavailable)
+		logRequest(r, route, nil, start, http.StatusServiceUnavailable)
+		return
 	}
+
+	backendRequestsTotal.WithLabelValues(route.Label(), peer.URL.String()).Inc()
+	activeConnections.WithLabelValues(route.Label(), peer.URL.String()).Inc()
+	defer activeConnections.WithLabelValues(route.Label(), peer.URL.String()).Dec()
+
+	peer.ServeHTTP(rec, r)
+
+	requestDuration.WithLabelValues(route.Label(), peer.URL.String(), strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+	logRequest(r, route, peer, start, rec.status)
 }
 
 func GetRetryFromContext(req *http.Request) int {
@@ -118,75 +148,13 @@ func GetAttemptsFromContext(req *http.Request) int {
 	return 0
 }
 
-func (s *ServerPool) MarkServiceDown(u *url.URL) {
-	for i := range s.backends {
-		if s.backends[i].URL.String() == u.String() {
-			s.backends[i].setAlive(false)
-		}
-	}
-}
-
-func (s *ServerPool) NextIndex() int {
-	return int(atomic.AddUint64(&s.current, uint64(1)) % uint64(len(s.backends)))
-}
-
-func (s *ServerPool) GetNextPeer() *Backend {
-	next := s.NextIndex()
-	l := len(s.backends) + next
-	for i := next; i < l; i++ {
-		idx := i % len(s.backends)
-
-		if s.backends[idx].isAlive() {
-			if i != next {
-				atomic.StoreUint64(&s.current, uint64(idx))
-			}
-			return s.backends[idx]
-		}
-	}
-	return nil
-}
-
-func (s *ServerPool) HealthCheck() {
-	for _, b := range s.backends {
-		status := "up"
-		alive := isBackendAlive(b.URL)
-		b.setAlive(alive)
-		if !alive {
-			status = "down"
-		}
-		log.Printf("%s [%s]\n", b.URL, status)
-	}
-}
-
-func (b *Backend) setAlive(alive bool) {
-	b.mux.Lock()
-	b.Alive = alive
-	b.mux.Unlock()
-}
-
-func (b *Backend) isAlive() (alive bool) {
-	b.mux.Lock()
-	alive = b.Alive
-	b.mux.Unlock()
-	return alive
-}
-
 func isBackendAlive(url *url.URL) bool {
 	timeout := 2 * time.Second
 	conn, err := net.DialTimeout("tcp", url.Host, timeout)
 	if err != nil {
-		log.Println("Server unreachable, error: ", err)
+		logger.Warn("backend unreachable", "upstream", url.String(), "error", err.Error())
 		return false
 	}
 	conn.Close()
 	return true
 }
-
-func healthCheck() {
-	t := time.NewTicker(time.Second * 20)
-	for range t.C {
-		log.Println("Start healthcheck")
-		serverPool.HealthCheck()
-		log.Println("Healthcheck completed")
-	}
-}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// logger emits structured JSON logs so operators can wire the load
+// balancer into the same observability stack as the rest of their
+// infrastructure.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// logRequest records one completed request: client, route, upstream,
+// how many attempts/retries it took, and how long it took.
+func logRequest(r *http.Request, route *Route, peer *Backend, start time.Time, status int) {
+	upstream := ""
+	if peer != nil {
+		upstream = peer.URL.String()
+	}
+	logger.Info("request",
+		"client_ip", clientIP(r),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"route", route.Label(),
+		"upstream", upstream,
+		"status", status,
+		"attempts", GetAttemptsFromContext(r),
+		"retries", GetRetryFromContext(r),
+		"latency_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_requests_total",
+		Help: "Total requests received, labeled by route.",
+	}, []string{"route"})
+
+	rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_rate_limited_total",
+		Help: "Total requests rejected by a route's rate limiter.",
+	}, []string{"route"})
+
+	backendRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_backend_requests_total",
+		Help: "Total requests proxied to a backend.",
+	}, []string{"route", "backend"})
+
+	backendErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_backend_errors_total",
+		Help: "Total proxy errors encountered per backend.",
+	}, []string{"route", "backend"})
+
+	retriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_retries_total",
+		Help: "Total retry attempts per backend.",
+	}, []string{"route", "backend"})
+
+	activeConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lb_backend_active_connections",
+		Help: "In-flight connections currently held open to a backend.",
+	}, []string{"route", "backend"})
+
+	backendUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lb_backend_up",
+		Help: "Health-check state of a backend (1 = alive, 0 = down).",
+	}, []string{"route", "backend"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lb_request_duration_seconds",
+		Help:    "End-to-end request duration as observed by the load balancer.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "backend", "status"})
+
+	cacheResultTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_cache_result_total",
+		Help: "Response cache outcomes, labeled by route and result (hit, stale, miss).",
+	}, []string{"route", "result"})
+)
+
+// responseRecorder wraps a ResponseWriter to capture the status code
+// and headers a backend responded with, for metrics and structured
+// logging. When buf is non-nil the response body is captured too, so
+// the response cache can store it alongside the status and headers.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	wroteHeader bool
+	buf         *bytes.Buffer
+}
+
+// newResponseRecorder builds a recorder around w. Pass captureBody
+// true to also buffer the response body, e.g. to populate the
+// response cache.
+func newResponseRecorder(w http.ResponseWriter, captureBody bool) *responseRecorder {
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	if captureBody {
+		rec.buf = &bytes.Buffer{}
+	}
+	return rec
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.status = code
+	r.header = r.Header().Clone()
+	r.wroteHeader = true
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.buf != nil {
+		r.buf.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush forwards to the underlying ResponseWriter's Flush, if it has
+// one, so streaming/SSE responses still flush through the wrapper.
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack forwards to the underlying ResponseWriter's Hijack, if it has
+// one, so protocol-upgrade backends (e.g. WebSocket) still work
+// through the wrapper instead of failing the proxy's type assertion.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
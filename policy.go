@@ -0,0 +1,223 @@
+package main
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// SelectionPolicy picks a backend from a pool for a given request. A
+// nil return means no backend is currently available.
+type SelectionPolicy interface {
+	Select(pool *ServerPool, r *http.Request) *Backend
+}
+
+// RoundRobinPolicy cycles through alive backends in order. It is the
+// default policy and matches the load balancer's original behavior.
+type RoundRobinPolicy struct {
+	current uint64
+}
+
+func (p *RoundRobinPolicy) Select(pool *ServerPool, r *http.Request) *Backend {
+	backends := pool.backends
+	if len(backends) == 0 {
+		return nil
+	}
+
+	next := int(atomic.AddUint64(&p.current, 1) % uint64(len(backends)))
+	l := len(backends) + next
+	for i := next; i < l; i++ {
+		idx := i % len(backends)
+		if backends[idx].available() {
+			if i != next {
+				atomic.StoreUint64(&p.current, uint64(idx))
+			}
+			return backends[idx]
+		}
+	}
+	return nil
+}
+
+// WeightedRoundRobinPolicy distributes requests proportionally to each
+// backend's Weight using the smooth weighted round-robin algorithm:
+// every backend accumulates its own weight each round, the backend
+// with the highest running total is picked, and that total is then
+// reduced by the sum of all weights. This keeps the distribution even
+// even across short bursts instead of clumping same-weight picks.
+type WeightedRoundRobinPolicy struct {
+	mux     sync.Mutex
+	current map[*Backend]int
+}
+
+func (p *WeightedRoundRobinPolicy) Select(pool *ServerPool, r *http.Request) *Backend {
+	alive := pool.AliveBackends()
+	if len(alive) == 0 {
+		return nil
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.current == nil {
+		p.current = make(map[*Backend]int)
+	}
+
+	totalWeight := 0
+	var best *Backend
+	for _, b := range alive {
+		weight := b.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		totalWeight += weight
+		p.current[b] += weight
+		if best == nil || p.current[b] > p.current[best] {
+			best = b
+		}
+	}
+
+	p.current[best] -= totalWeight
+	return best
+}
+
+// LeastConnectionsPolicy routes to the alive backend with the fewest
+// active connections.
+type LeastConnectionsPolicy struct{}
+
+func (p *LeastConnectionsPolicy) Select(pool *ServerPool, r *http.Request) *Backend {
+	alive := pool.AliveBackends()
+	if len(alive) == 0 {
+		return nil
+	}
+
+	best := alive[0]
+	for _, b := range alive[1:] {
+		if b.ActiveConns.Load() < best.ActiveConns.Load() {
+			best = b
+		}
+	}
+	return best
+}
+
+// HashKeySource controls what ConsistentHashPolicy hashes to pick a
+// backend.
+type HashKeySource int
+
+const (
+	// HashByClientIP hashes the request's remote IP (RemoteAddr).
+	HashByClientIP HashKeySource = iota
+	// HashByHeader hashes the value of HeaderName on the request.
+	HashByHeader
+)
+
+const virtualNodesPerBackend = 100
+
+// ConsistentHashPolicy builds a hash ring over the pool's backends so
+// that requests sharing a key (client IP or a header, e.g. a session
+// cookie) consistently land on the same upstream, which keeps retries
+// and sticky sessions stable as backends come and go.
+type ConsistentHashPolicy struct {
+	KeySource  HashKeySource
+	HeaderName string
+
+	mux     sync.RWMutex
+	ring    map[uint32]*Backend
+	sorted  []uint32
+	builtOn uint64
+}
+
+func (p *ConsistentHashPolicy) Select(pool *ServerPool, r *http.Request) *Backend {
+	healthy := pool.HealthyBackends()
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	p.ensureRing(healthy)
+
+	key := p.key(r)
+	h := hashKey(key)
+
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	idx := sort.Search(len(p.sorted), func(i int) bool { return p.sorted[i] >= h })
+	if idx == len(p.sorted) {
+		idx = 0
+	}
+
+	// Walk the ring forward until we land on a backend that can
+	// actually take the request; a healthy backend may still be
+	// skipped here because it's momentarily saturated, without that
+	// counting as a ring membership change.
+	for i := 0; i < len(p.sorted); i++ {
+		b := p.ring[p.sorted[(idx+i)%len(p.sorted)]]
+		if b.available() {
+			return b
+		}
+	}
+	return nil
+}
+
+func (p *ConsistentHashPolicy) key(r *http.Request) string {
+	if p.KeySource == HashByHeader && p.HeaderName != "" {
+		if v := r.Header.Get(p.HeaderName); v != "" {
+			return v
+		}
+	}
+	return r.RemoteAddr
+}
+
+func (p *ConsistentHashPolicy) ensureRing(healthy []*Backend) {
+	fingerprint := backendsFingerprint(healthy)
+
+	p.mux.RLock()
+	built := p.builtOn == fingerprint
+	p.mux.RUnlock()
+	if built {
+		return
+	}
+
+	ring := make(map[uint32]*Backend, len(healthy)*virtualNodesPerBackend)
+	sorted := make([]uint32, 0, len(healthy)*virtualNodesPerBackend)
+	for _, b := range healthy {
+		for i := 0; i < virtualNodesPerBackend; i++ {
+			h := hashKey(b.URL.String() + "#" + strconv.Itoa(i))
+			ring[h] = b
+			sorted = append(sorted, h)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	p.mux.Lock()
+	p.ring = ring
+	p.sorted = sorted
+	p.builtOn = fingerprint
+	p.mux.Unlock()
+}
+
+// backendsFingerprint identifies a set of backends by identity, not
+// just size, so the ring is rebuilt whenever membership changes even
+// if the count happens to stay the same (e.g. one backend replaces
+// another in the same health-check tick).
+func backendsFingerprint(backends []*Backend) uint64 {
+	urls := make([]string, len(backends))
+	for i, b := range backends {
+		urls[i] = b.URL.String()
+	}
+	sort.Strings(urls)
+
+	h := fnv.New64a()
+	for _, u := range urls {
+		_, _ = h.Write([]byte(u))
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
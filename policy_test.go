@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func mustBackend(t *testing.T, rawURL string) *Backend {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse backend url %q: %v", rawURL, err)
+	}
+	b := &Backend{URL: u, Weight: 1}
+	b.setAlive(true)
+	return b
+}
+
+func TestConsistentHashPolicyStableForSameKey(t *testing.T) {
+	a := mustBackend(t, "http://backend-a:8080")
+	b := mustBackend(t, "http://backend-b:8080")
+	c := mustBackend(t, "http://backend-c:8080")
+	pool := NewServerPool(&ConsistentHashPolicy{KeySource: HashByClientIP})
+	pool.AddBackend(a)
+	pool.AddBackend(b)
+	pool.AddBackend(c)
+
+	req := &http.Request{RemoteAddr: "10.0.0.7:54321"}
+	first := pool.NextPeer(req)
+	if first == nil {
+		t.Fatal("expected a backend, got nil")
+	}
+	for i := 0; i < 20; i++ {
+		got := pool.NextPeer(req)
+		if got != first {
+			t.Fatalf("iteration %d: key routed to %v, want stable %v", i, got.URL, first.URL)
+		}
+	}
+}
+
+func TestConsistentHashPolicyRebuildsOnMembershipChangeWithSameCount(t *testing.T) {
+	a := mustBackend(t, "http://backend-a:8080")
+	b := mustBackend(t, "http://backend-b:8080")
+	policy := &ConsistentHashPolicy{KeySource: HashByClientIP}
+	pool := NewServerPool(policy)
+	pool.AddBackend(a)
+	pool.AddBackend(b)
+
+	req := &http.Request{RemoteAddr: "10.0.0.7:54321"}
+	if got := pool.NextPeer(req); got == nil {
+		t.Fatal("expected a backend, got nil")
+	}
+
+	// Swap b out for a brand new backend c without changing the alive
+	// count. A ring keyed only on len(alive) would miss this and keep
+	// routing to a's or b's virtual nodes.
+	c := mustBackend(t, "http://backend-c:8080")
+	b.setAlive(false)
+	pool.AddBackend(c)
+
+	healthy := pool.HealthyBackends()
+	if len(healthy) != 2 {
+		t.Fatalf("expected 2 healthy backends after swap, got %d", len(healthy))
+	}
+
+	policy.ensureRing(healthy)
+	for _, h := range policy.sorted {
+		if policy.ring[h] == b {
+			t.Fatalf("ring still references backend b after it was swapped out for c")
+		}
+	}
+
+	sawC := false
+	for _, backend := range policy.ring {
+		if backend == c {
+			sawC = true
+			break
+		}
+	}
+	if !sawC {
+		t.Fatal("ring does not contain any virtual nodes for the newly alive backend c")
+	}
+}
+
+func TestConsistentHashPolicyDoesNotRebuildOnSaturation(t *testing.T) {
+	a := mustBackend(t, "http://backend-a:8080")
+	b := mustBackend(t, "http://backend-b:8080")
+	a.MaxInFlight = 1
+	policy := &ConsistentHashPolicy{KeySource: HashByClientIP}
+	pool := NewServerPool(policy)
+	pool.AddBackend(a)
+	pool.AddBackend(b)
+
+	req := &http.Request{RemoteAddr: "10.0.0.7:54321"}
+	if got := pool.NextPeer(req); got == nil {
+		t.Fatal("expected a backend, got nil")
+	}
+	builtOn := policy.builtOn
+
+	// Saturate a without changing anyone's alive status. Membership
+	// (HealthyBackends) hasn't changed, so the ring must not rebuild.
+	a.ActiveConns.Add(1)
+	if got := pool.NextPeer(req); got == nil {
+		t.Fatal("expected a backend, got nil")
+	}
+	if policy.builtOn != builtOn {
+		t.Fatal("ring was rebuilt due to saturation alone, not a membership change")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// ServerPool holds the set of backends for a route and the policy used
+// to pick one of them for a given request.
+type ServerPool struct {
+	backends []*Backend
+	policy   SelectionPolicy
+}
+
+// NewServerPool creates a ServerPool that selects backends using the
+// given policy. If policy is nil, round-robin is used.
+func NewServerPool(policy SelectionPolicy) *ServerPool {
+	if policy == nil {
+		policy = &RoundRobinPolicy{}
+	}
+	return &ServerPool{policy: policy}
+}
+
+func (s *ServerPool) AddBackend(backend *Backend) {
+	s.backends = append(s.backends, backend)
+}
+
+// AliveBackends returns the subset of backends currently available to
+// take a request (alive and under their concurrency cap).
+func (s *ServerPool) AliveBackends() []*Backend {
+	alive := make([]*Backend, 0, len(s.backends))
+	for _, b := range s.backends {
+		if b.available() {
+			alive = append(alive, b)
+		}
+	}
+	return alive
+}
+
+// HealthyBackends returns the subset of backends that are alive,
+// regardless of whether they're currently saturated. Policies that
+// care about stable membership (e.g. the consistent-hash ring) should
+// use this instead of AliveBackends: saturation flips on and off with
+// in-flight request counts and isn't a membership change.
+func (s *ServerPool) HealthyBackends() []*Backend {
+	healthy := make([]*Backend, 0, len(s.backends))
+	for _, b := range s.backends {
+		if b.isAlive() {
+			healthy = append(healthy, b)
+		}
+	}
+	return healthy
+}
+
+func (s *ServerPool) MarkServiceDown(u *url.URL) {
+	for i := range s.backends {
+		if s.backends[i].URL.String() == u.String() {
+			s.backends[i].setAlive(false)
+		}
+	}
+}
+
+// NextPeer returns the backend chosen by the pool's selection policy
+// for the given request, or nil if no backend is available.
+func (s *ServerPool) NextPeer(r *http.Request) *Backend {
+	if len(s.backends) == 0 {
+		return nil
+	}
+	return s.policy.Select(s, r)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// clientLimiterIdleTTL is how long a per-client limiter may sit unused
+// before the janitor reclaims it, bounding memory for routes fronting
+// many distinct client IPs over time.
+const clientLimiterIdleTTL = 10 * time.Minute
+
+// RouteLimiter enforces a route's global and per-client-IP token
+// buckets. Either or both may be nil, in which case that bucket is not
+// enforced.
+type RouteLimiter struct {
+	global *rate.Limiter
+
+	clientRPS   rate.Limit
+	clientBurst int
+	clientsMux  sync.Mutex
+	clients     map[string]*clientLimiter
+}
+
+// clientLimiter pairs a per-client token bucket with the last time it
+// was used, so the janitor can evict limiters that have gone idle.
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRouteLimiter builds a RouteLimiter from YAML config. A nil config
+// for either bucket disables it.
+func NewRouteLimiter(global, perClient *RateLimitConfig) *RouteLimiter {
+	rl := &RouteLimiter{}
+	if global != nil {
+		rl.global = rate.NewLimiter(rate.Limit(global.RPS), global.Burst)
+	}
+	if perClient != nil {
+		rl.clientRPS = rate.Limit(perClient.RPS)
+		rl.clientBurst = perClient.Burst
+		rl.clients = make(map[string]*clientLimiter)
+	}
+	return rl
+}
+
+// Allow reports whether a request may proceed. When it may not, the
+// returned duration is how long the caller should wait before
+// retrying (for a Retry-After header).
+func (rl *RouteLimiter) Allow(r *http.Request) (bool, time.Duration) {
+	if rl.global != nil {
+		if ok, wait := reserve(rl.global); !ok {
+			return false, wait
+		}
+	}
+	if rl.clients != nil {
+		if ok, wait := reserve(rl.limiterFor(clientIP(r))); !ok {
+			return false, wait
+		}
+	}
+	return true, 0
+}
+
+func (rl *RouteLimiter) limiterFor(ip string) *rate.Limiter {
+	rl.clientsMux.Lock()
+	defer rl.clientsMux.Unlock()
+
+	entry, ok := rl.clients[ip]
+	if !ok {
+		entry = &clientLimiter{limiter: rate.NewLimiter(rl.clientRPS, rl.clientBurst)}
+		rl.clients[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+// janitor periodically evicts per-client limiters that have been idle
+// past clientLimiterIdleTTL, until stop is closed. A route with no
+// per-client limiter configured (clients == nil) has nothing to do.
+func (rl *RouteLimiter) janitor(stop <-chan struct{}) {
+	if rl.clients == nil {
+		return
+	}
+
+	t := time.NewTicker(clientLimiterIdleTTL)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			rl.evictIdle(time.Now().Add(-clientLimiterIdleTTL))
+		}
+	}
+}
+
+func (rl *RouteLimiter) evictIdle(cutoff time.Time) {
+	rl.clientsMux.Lock()
+	defer rl.clientsMux.Unlock()
+
+	for ip, entry := range rl.clients {
+		if entry.lastSeen.Before(cutoff) {
+			delete(rl.clients, ip)
+		}
+	}
+}
+
+// reserve takes a token from lim without blocking. If none is
+// available right now it cancels the reservation (so it doesn't
+// borrow against future capacity) and reports how long until one
+// would be.
+func reserve(lim *rate.Limiter) (bool, time.Duration) {
+	res := lim.Reserve()
+	if !res.OK() {
+		return false, time.Second
+	}
+	if delay := res.Delay(); delay > 0 {
+		res.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
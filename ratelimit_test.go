@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRouteLimiterAllowExhaustsAndRecoversBurst(t *testing.T) {
+	rl := NewRouteLimiter(nil, &RateLimitConfig{RPS: 10, Burst: 2})
+
+	req := &http.Request{RemoteAddr: "10.0.0.1:1234"}
+
+	for i := 0; i < 2; i++ {
+		ok, wait := rl.Allow(req)
+		if !ok {
+			t.Fatalf("request %d: expected burst capacity to allow it, got wait=%v", i, wait)
+		}
+	}
+
+	ok, wait := rl.Allow(req)
+	if ok {
+		t.Fatal("expected burst to be exhausted")
+	}
+	if wait <= 0 {
+		t.Fatalf("expected a positive retry-after wait, got %v", wait)
+	}
+
+	time.Sleep(wait)
+
+	if ok, wait := rl.Allow(req); !ok {
+		t.Fatalf("expected the bucket to have recovered after waiting %v, got wait=%v", wait, wait)
+	}
+}
+
+func TestRouteLimiterPerClientIsolation(t *testing.T) {
+	rl := NewRouteLimiter(nil, &RateLimitConfig{RPS: 10, Burst: 1})
+
+	reqA := &http.Request{RemoteAddr: "10.0.0.1:1111"}
+	reqB := &http.Request{RemoteAddr: "10.0.0.2:2222"}
+
+	if ok, wait := rl.Allow(reqA); !ok {
+		t.Fatalf("client A's first request should be allowed, got wait=%v", wait)
+	}
+	if ok, _ := rl.Allow(reqA); ok {
+		t.Fatal("client A's second request should be rate-limited")
+	}
+
+	if ok, wait := rl.Allow(reqB); !ok {
+		t.Fatalf("client B should have its own bucket and not be limited by A's usage, got wait=%v", wait)
+	}
+}
+
+func TestRouteLimiterJanitorEvictsIdleClients(t *testing.T) {
+	rl := NewRouteLimiter(nil, &RateLimitConfig{RPS: 10, Burst: 1})
+	rl.Allow(&http.Request{RemoteAddr: "10.0.0.1:1111"})
+
+	if len(rl.clients) != 1 {
+		t.Fatalf("expected 1 tracked client, got %d", len(rl.clients))
+	}
+
+	rl.evictIdle(time.Now().Add(time.Minute))
+
+	if len(rl.clients) != 0 {
+		t.Fatalf("expected idle client to be evicted, still have %d", len(rl.clients))
+	}
+}
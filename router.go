@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Route pairs a host/path-prefix match with the backend pool that
+// should serve it.
+type Route struct {
+	Host       string
+	Prefix     string
+	Pool       *ServerPool
+	RetryCount int
+	RetryDelay time.Duration
+	Active     *ActiveHealthChecker
+	Passive    *PassiveBreakerConfig
+	Limiter    *RouteLimiter
+	Cache      *RouteCache
+}
+
+// Router dispatches incoming requests to the Route whose host/prefix
+// best matches, and falls back to trying the remaining routes on a
+// miss.
+type Router struct {
+	routes []*Route
+	stop   chan struct{}
+}
+
+// NewRouter builds a Router with one ServerPool per configured route
+// and starts each route's health-check goroutine. Call Stop when the
+// router is replaced (e.g. on config reload) to stop those goroutines.
+func NewRouter(cfg *Config) (*Router, error) {
+	rt := &Router{stop: make(chan struct{})}
+	for _, rc := range cfg.Routes {
+		route, err := buildRoute(rc)
+		if err != nil {
+			return nil, err
+		}
+		rt.routes = append(rt.routes, route)
+	}
+	for _, route := range rt.routes {
+		go route.runHealthChecks(rt.stop)
+		go route.Limiter.janitor(rt.stop)
+	}
+	return rt, nil
+}
+
+// Stop ends all of the router's health-check goroutines.
+func (rt *Router) Stop() {
+	close(rt.stop)
+}
+
+// Label identifies a route for metrics and logs.
+func (route *Route) Label() string {
+	if route.Host == "" {
+		return route.Prefix
+	}
+	return route.Host + route.Prefix
+}
+
+// Match returns the most specific Route for the request (exact host
+// match preferred, then longest path prefix), or nil if none match.
+func (rt *Router) Match(r *http.Request) *Route {
+	var best *Route
+	for _, route := range rt.routes {
+		if route.Host != "" && route.Host != r.Host {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, route.Prefix) {
+			continue
+		}
+		if best == nil || len(route.Prefix) > len(best.Prefix) || (route.Host != "" && best.Host == "") {
+			best = route
+		}
+	}
+	return best
+}
+
+func buildPolicy(rc RouteConfig) (SelectionPolicy, error) {
+	switch rc.Policy {
+	case "", "round_robin":
+		return &RoundRobinPolicy{}, nil
+	case "weighted_round_robin":
+		return &WeightedRoundRobinPolicy{}, nil
+	case "least_connections":
+		return &LeastConnectionsPolicy{}, nil
+	case "consistent_hash":
+		p := &ConsistentHashPolicy{}
+		if strings.HasPrefix(rc.HashKey, "header:") {
+			p.KeySource = HashByHeader
+			p.HeaderName = strings.TrimPrefix(rc.HashKey, "header:")
+		} else {
+			p.KeySource = HashByClientIP
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown selection policy %q", rc.Policy)
+	}
+}
+
+func buildRoute(rc RouteConfig) (*Route, error) {
+	policy, err := buildPolicy(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	route := &Route{
+		Host:       rc.Host,
+		Prefix:     rc.Prefix,
+		Pool:       NewServerPool(policy),
+		RetryCount: rc.Retries(),
+		RetryDelay: rc.RetryDelay(),
+		Limiter:    NewRouteLimiter(rc.RateLimit, rc.ClientRateLimit),
+	}
+	if rc.HealthCheck.Active != nil {
+		route.Active = NewActiveHealthChecker(*rc.HealthCheck.Active)
+	}
+	if rc.HealthCheck.Passive != nil {
+		route.Passive = NewPassiveBreakerConfig(*rc.HealthCheck.Passive)
+	}
+	if rc.Cache != nil && rc.Cache.Enabled {
+		route.Cache = NewRouteCache(*rc.Cache)
+	}
+
+	for _, bc := range rc.Backends {
+		u, err := url.Parse(bc.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parse backend url %q: %w", bc.URL, err)
+		}
+
+		backend := &Backend{
+			URL:         u,
+			Weight:      bc.Weight,
+			MaxInFlight: bc.MaxInFlight,
+		}
+		if backend.Weight <= 0 {
+			backend.Weight = 1
+		}
+		if route.Passive != nil {
+			backend.failures = newFailureWindow(route.Passive.Window)
+		}
+
+		b := backend
+		onError := func(w http.ResponseWriter, req *http.Request, e error) {
+			logger.Error("backend error", "route", route.Label(), "upstream", b.URL.String(), "error", e.Error())
+			backendErrorsTotal.WithLabelValues(route.Label(), b.URL.String()).Inc()
+
+			retries := GetRetryFromContext(req)
+			if retries < route.RetryCount {
+				retriesTotal.WithLabelValues(route.Label(), b.URL.String()).Inc()
+				time.Sleep(route.RetryDelay)
+				ctx := context.WithValue(req.Context(), Retry, retries+1)
+				b.Upstream.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+
+			route.RecordFailure(b)
+			attempts := GetAttemptsFromContext(req)
+			ctx := context.WithValue(req.Context(), Attempts, attempts+1)
+			lb(w, req.WithContext(ctx))
+		}
+
+		switch u.Scheme {
+		case "fastcgi":
+			backend.Upstream = newFCGIUpstream(u, rc.ScriptRoot, rc.BackendTimeout(), onError)
+		default:
+			backend.Upstream = newHTTPUpstream(u, rc.BackendTimeout(), onError)
+		}
+
+		backend.setAlive(true)
+		route.Pool.AddBackend(backend)
+	}
+
+	return route, nil
+}
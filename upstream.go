@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Upstream is the transport used to serve a request to a backend. The
+// pool, selection policies, and health checking only ever deal with a
+// Backend; the Upstream is what actually speaks to the process behind
+// it, whether that's plain HTTP or FastCGI.
+type Upstream interface {
+	http.Handler
+	Kind() string
+}
+
+// ErrorHandlerFunc reports a failure to serve a request through an
+// Upstream so the route can retry it or mark the backend down.
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+
+// httpUpstream proxies over HTTP using httputil.ReverseProxy, the
+// load balancer's original and still most common transport.
+type httpUpstream struct {
+	proxy *httputil.ReverseProxy
+}
+
+// newHTTPUpstream builds the HTTP upstream for a backend whose URL
+// scheme is http/https.
+func newHTTPUpstream(target *url.URL, timeout time.Duration, onError ErrorHandlerFunc) *httpUpstream {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	if timeout > 0 {
+		proxy.Transport = &http.Transport{ResponseHeaderTimeout: timeout}
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		onError(w, r, err)
+	}
+	return &httpUpstream{proxy: proxy}
+}
+
+func (u *httpUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.proxy.ServeHTTP(w, r)
+}
+
+func (u *httpUpstream) Kind() string { return "http" }
+
+// fcgiUpstream proxies over the FastCGI protocol to a worker such as
+// PHP-FPM, selected by a fastcgi:// backend URL.
+type fcgiUpstream struct {
+	network    string // "tcp" or "unix"
+	addr       string
+	scriptRoot string
+	timeout    time.Duration
+	onError    ErrorHandlerFunc
+}
+
+// newFCGIUpstream builds the FastCGI upstream for a backend whose URL
+// scheme is fastcgi. scriptRoot, if set, is joined with the request
+// path to build SCRIPT_FILENAME for workers like PHP-FPM that require it.
+func newFCGIUpstream(target *url.URL, scriptRoot string, timeout time.Duration, onError ErrorHandlerFunc) *fcgiUpstream {
+	network := "tcp"
+	addr := target.Host
+	if target.Host == "" {
+		network = "unix"
+		addr = target.Path
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &fcgiUpstream{network: network, addr: addr, scriptRoot: scriptRoot, timeout: timeout, onError: onError}
+}
+
+func (u *fcgiUpstream) Kind() string { return "fastcgi" }
+
+func (u *fcgiUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := u.serve(w, r); err != nil {
+		u.onError(w, r, err)
+	}
+}
+
+func (u *fcgiUpstream) serve(w http.ResponseWriter, r *http.Request) error {
+	conn, err := net.DialTimeout(u.network, u.addr, u.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(u.timeout))
+
+	status, header, body, err := (&fcgiClient{conn: conn}).Do(u.params(r), r.Body)
+	if err != nil {
+		return err
+	}
+
+	for key, values := range header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+func (u *fcgiUpstream) params(r *http.Request) map[string]string {
+	scriptName := r.URL.Path
+	scriptFilename := scriptName
+	if u.scriptRoot != "" {
+		scriptFilename = path.Join(u.scriptRoot, scriptName)
+	}
+
+	params := map[string]string{
+		"REQUEST_METHOD":  r.Method,
+		"SCRIPT_NAME":     scriptName,
+		"SCRIPT_FILENAME": scriptFilename,
+		"QUERY_STRING":    r.URL.RawQuery,
+		"SERVER_PROTOCOL": r.Proto,
+		"CONTENT_TYPE":    r.Header.Get("Content-Type"),
+		"CONTENT_LENGTH":  strconv.FormatInt(r.ContentLength, 10),
+		"REMOTE_ADDR":     clientIP(r),
+	}
+	for name, values := range r.Header {
+		params["HTTP_"+headerParamName(name)] = strings.Join(values, ", ")
+	}
+	return params
+}
+
+func headerParamName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}